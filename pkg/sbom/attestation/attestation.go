@@ -0,0 +1,156 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package attestation wraps an already-rendered SBOM document (CycloneDX or
+// SPDX) as an in-toto v1.0 Statement and, optionally, DSSE-signs it so the
+// envelope can be pushed alongside the image as an OCI referrer. Generators
+// call Generate once their SBOM has been written to disk; the signing
+// backend itself (keyless Fulcio, or a file-based key) is resolved from
+// options.AttestationOptions so callers configure it the same way they
+// configure SBOM generation.
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+// statementType is the in-toto Statement layer type URI for v1.0 statements.
+const statementType = "https://in-toto.io/Statement/v1"
+
+// payloadType is the DSSE payload type apko attestations are signed under,
+// matching the convention cosign and other in-toto producers use for
+// in-toto statements.
+const payloadType = "application/vnd.in-toto+json"
+
+// PredicateType identifies the SBOM format a Statement wraps.
+type PredicateType string
+
+const (
+	// CycloneDXPredicateType is the in-toto predicate type for a CycloneDX
+	// BOM predicate.
+	CycloneDXPredicateType PredicateType = "https://cyclonedx.org/bom"
+	// SPDXPredicateType is the in-toto predicate type for an SPDX document
+	// predicate.
+	SPDXPredicateType PredicateType = "https://spdx.dev/Document"
+)
+
+// Subject identifies one of the artifacts a Statement makes claims about,
+// per the in-toto v1.0 Statement layer.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statement is an in-toto v1.0 Statement wrapping an apko-generated SBOM as
+// its predicate.
+type Statement struct {
+	Type          string          `json:"_type"`
+	PredicateType PredicateType   `json:"predicateType"`
+	Subject       []Subject       `json:"subject"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Options configures a single call to Generate.
+type Options struct {
+	// PredicateType selects the predicate the SBOM is wrapped as. Generators
+	// set this to CycloneDXPredicateType or SPDXPredicateType before calling
+	// Generate.
+	PredicateType PredicateType
+	// Signer produces the DSSE envelope wrapping the Statement. A nil Signer
+	// leaves the attestation unsigned: Generate writes the bare Statement
+	// JSON to outPath instead of a DSSE envelope.
+	Signer dsse.SignVerifier
+}
+
+// Generate reads the SBOM at sbomPath, wraps it as an in-toto v1.0 Statement
+// with its subject populated from opts.ImageInfo, and writes the result to
+// outPath. When attOpts.Signer is set the Statement is DSSE-signed first;
+// otherwise the Statement JSON is written as-is.
+func Generate(opts *options.Options, attOpts Options, sbomPath, outPath string) error {
+	body, err := os.ReadFile(sbomPath)
+	if err != nil {
+		return fmt.Errorf("reading SBOM %s: %w", sbomPath, err)
+	}
+
+	stmt := Statement{
+		Type:          statementType,
+		PredicateType: attOpts.PredicateType,
+		Subject:       []Subject{subjectFor(opts)},
+		Predicate:     json.RawMessage(body),
+	}
+
+	payload, err := json.Marshal(stmt)
+	if err != nil {
+		return fmt.Errorf("marshaling in-toto statement: %w", err)
+	}
+
+	if attOpts.Signer == nil {
+		if err := os.WriteFile(outPath, payload, 0o644); err != nil {
+			return fmt.Errorf("writing attestation %s: %w", outPath, err)
+		}
+		return nil
+	}
+
+	signer, err := dsse.NewEnvelopeSigner(attOpts.Signer)
+	if err != nil {
+		return fmt.Errorf("constructing DSSE envelope signer: %w", err)
+	}
+
+	env, err := signer.SignPayload(payloadType, payload)
+	if err != nil {
+		return fmt.Errorf("DSSE-signing attestation: %w", err)
+	}
+
+	out, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshaling DSSE envelope: %w", err)
+	}
+
+	if err := os.WriteFile(outPath, out, 0o644); err != nil {
+		return fmt.Errorf("writing attestation %s: %w", outPath, err)
+	}
+
+	return nil
+}
+
+// subjectFor derives the attestation subject from the image apko just built:
+// the single image digest for a per-arch SBOM, falling back to the index
+// digest when this is the top-level, multi-arch attestation.
+func subjectFor(opts *options.Options) Subject {
+	algo, hex := "sha256", ""
+	switch {
+	case opts.ImageInfo.ImageDigest != "":
+		hex = strings.TrimPrefix(opts.ImageInfo.ImageDigest, "sha256:")
+	default:
+		h := opts.ImageInfo.IndexDigest.DeepCopy()
+		algo, hex = h.Algorithm, h.Hex
+	}
+
+	name := opts.ImageInfo.Name
+	if name == "" {
+		name = hex
+	}
+
+	return Subject{
+		Name:   name,
+		Digest: map[string]string{algo: hex},
+	}
+}
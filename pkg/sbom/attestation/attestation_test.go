@@ -0,0 +1,65 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attestation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+const testDigestHex = "0000000000000000000000000000000000000000000000000000000000000000"[:64]
+
+func TestGenerateUnsigned(t *testing.T) {
+	dir := t.TempDir()
+	sbomPath := filepath.Join(dir, "sbom.cdx.json")
+	if err := os.WriteFile(sbomPath, []byte(`{"bomFormat":"CycloneDX"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outPath := filepath.Join(dir, "sbom.cdx.intoto.json")
+
+	opts := &options.Options{}
+	opts.ImageInfo.Name = "cgr.dev/chainguard/static"
+	opts.ImageInfo.ImageDigest = "sha256:" + testDigestHex
+
+	if err := Generate(opts, Options{PredicateType: CycloneDXPredicateType}, sbomPath, outPath); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	raw, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stmt Statement
+	if err := json.Unmarshal(raw, &stmt); err != nil {
+		t.Fatalf("decoding statement: %v", err)
+	}
+	if stmt.Type != statementType {
+		t.Errorf("Type = %q, want %q", stmt.Type, statementType)
+	}
+	if stmt.PredicateType != CycloneDXPredicateType {
+		t.Errorf("PredicateType = %q, want %q", stmt.PredicateType, CycloneDXPredicateType)
+	}
+	if len(stmt.Subject) != 1 || stmt.Subject[0].Name != opts.ImageInfo.Name {
+		t.Errorf("Subject = %+v, want a single subject named %q", stmt.Subject, opts.ImageInfo.Name)
+	}
+	if stmt.Subject[0].Digest["sha256"] != testDigestHex {
+		t.Errorf("Subject digest = %+v, want sha256:%s", stmt.Subject[0].Digest, testDigestHex)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attestation
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+// NewSigner resolves opts into a dsse.SignVerifier ready to sign a Statement,
+// or nil if attestations should be emitted unsigned.
+//
+// AttestationBackendKeyless is not implemented here: exchanging an ambient OIDC
+// identity for a short-lived Fulcio certificate needs network access and a
+// credential provider, which belongs in the cmd layer (apko publish) rather
+// than pkg/sbom. Callers that want keyless signing supply the resulting
+// signer directly via opts.KeylessSigner.
+func NewSigner(opts options.AttestationOptions) (dsse.SignVerifier, error) {
+	switch opts.Backend {
+	case options.AttestationBackendNone, "":
+		return nil, nil
+	case options.AttestationBackendKey:
+		return newFileKeySigner(opts.KeyPath)
+	case options.AttestationBackendKeyless:
+		if opts.KeylessSigner == nil {
+			return nil, fmt.Errorf("keyless attestation signing requires opts.KeylessSigner to be set by the caller (e.g. apko publish's Fulcio client)")
+		}
+		return opts.KeylessSigner, nil
+	default:
+		return nil, fmt.Errorf("unknown attestation signing backend %q", opts.Backend)
+	}
+}
+
+// fileKeySigner DSSE-signs attestations with an ed25519 key loaded from
+// disk, for callers that manage their own signing keys instead of using
+// keyless Fulcio certificates.
+type fileKeySigner struct {
+	priv  ed25519.PrivateKey
+	pub   ed25519.PublicKey
+	keyID string
+}
+
+// newFileKeySigner reads a PKCS#8 PEM-encoded ed25519 private key from path.
+func newFileKeySigner(path string) (*fileKeySigner, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading attestation signing key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM-encoded key", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing attestation signing key %s: %w", path, err)
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: only ed25519 keys are supported for file-based attestation signing", path)
+	}
+
+	pub, ok := priv.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: failed to derive ed25519 public key", path)
+	}
+	sum := sha256.Sum256(pub)
+
+	return &fileKeySigner{priv: priv, pub: pub, keyID: hex.EncodeToString(sum[:])}, nil
+}
+
+func (s *fileKeySigner) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.priv, data), nil
+}
+
+func (s *fileKeySigner) KeyID() (string, error) {
+	return s.keyID, nil
+}
+
+func (s *fileKeySigner) Verify(data, sig []byte) error {
+	if !ed25519.Verify(s.pub, data, sig) {
+		return fmt.Errorf("ed25519 signature verification failed")
+	}
+	return nil
+}
+
+func (s *fileKeySigner) Public() crypto.PublicKey {
+	return s.pub
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attestation
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+// writeKeyFile PEM-encodes key as a PKCS#8 block and writes it to a file
+// under t.TempDir(), returning its path.
+func writeKeyFile(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewFileKeySignerRoundTrip(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeKeyFile(t, priv)
+
+	signer, err := newFileKeySigner(path)
+	if err != nil {
+		t.Fatalf("newFileKeySigner: %v", err)
+	}
+
+	data := []byte("sign me")
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := signer.Verify(data, sig); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := signer.Verify([]byte("not the data"), sig); err == nil {
+		t.Error("Verify succeeded on tampered data, want an error")
+	}
+	if keyID, err := signer.KeyID(); err != nil || keyID == "" {
+		t.Errorf("KeyID = %q, %v, want a non-empty id", keyID, err)
+	}
+}
+
+func TestNewFileKeySignerMissingFile(t *testing.T) {
+	if _, err := newFileKeySigner(filepath.Join(t.TempDir(), "does-not-exist.pem")); err == nil {
+		t.Error("newFileKeySigner succeeded on a missing file, want an error")
+	}
+}
+
+func TestNewFileKeySignerNotPEM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, []byte("not a pem file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileKeySigner(path); err == nil {
+		t.Error("newFileKeySigner succeeded on non-PEM content, want an error")
+	}
+}
+
+func TestNewFileKeySignerNotPKCS8(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not a valid PKCS8 DER payload")}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileKeySigner(path); err == nil {
+		t.Error("newFileKeySigner succeeded on malformed PKCS8, want an error")
+	}
+}
+
+func TestNewFileKeySignerWrongKeyType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeKeyFile(t, rsaKey)
+
+	if _, err := newFileKeySigner(path); err == nil {
+		t.Error("newFileKeySigner succeeded on an RSA key, want an error since only ed25519 is supported")
+	}
+}
+
+func TestNewSignerNone(t *testing.T) {
+	signer, err := NewSigner(options.AttestationOptions{})
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	if signer != nil {
+		t.Errorf("signer = %v, want nil for the default (unsigned) backend", signer)
+	}
+}
+
+func TestNewSignerKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeKeyFile(t, priv)
+
+	signer, err := NewSigner(options.AttestationOptions{
+		Backend: options.AttestationBackendKey,
+		KeyPath: path,
+	})
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	if signer == nil {
+		t.Fatal("signer = nil, want a fileKeySigner")
+	}
+}
+
+func TestNewSignerKeylessRequiresSigner(t *testing.T) {
+	if _, err := NewSigner(options.AttestationOptions{Backend: options.AttestationBackendKeyless}); err == nil {
+		t.Error("NewSigner succeeded with AttestationBackendKeyless and no KeylessSigner, want an error")
+	}
+}
+
+func TestNewSignerUnknownBackend(t *testing.T) {
+	if _, err := NewSigner(options.AttestationOptions{Backend: "bogus"}); err == nil {
+		t.Error("NewSigner succeeded with an unknown backend, want an error")
+	}
+}
@@ -15,90 +15,192 @@
 package cyclonedx
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
+	"sort"
+	"time"
 
+	cdx "github.com/CycloneDX/cyclonedx-go"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	purl "github.com/package-url/packageurl-go"
 
+	"chainguard.dev/apko/pkg/sbom"
+	"chainguard.dev/apko/pkg/sbom/attestation"
+	"chainguard.dev/apko/pkg/sbom/generator"
 	"chainguard.dev/apko/pkg/sbom/options"
 )
 
-type CycloneDX struct{}
+// unresolvedDependencyProperty is the CycloneDX property name used to record
+// a dependency token that no installed package provides, instead of
+// silently dropping it from the graph.
+const unresolvedDependencyProperty = "apko:unresolved-dependency"
+
+// osLayerDescription and imageComponentDescription identify the two wrapper
+// components Generate nests a package list under (OS layer, then
+// optionally a container image). GenerateIndex's archPackageComponents matches
+// on these to drill down to the actual package list when folding an arch's
+// document into the index, instead of re-nesting the wrapper itself.
+const (
+	osLayerDescription        = "apko OS layer"
+	imageComponentDescription = "apko container image"
+)
+
+// Format selects the serialization used when a document is rendered to disk.
+type Format int
+
+const (
+	// FormatJSON renders the document as CycloneDX JSON. This is the default.
+	FormatJSON Format = iota
+	// FormatXML renders the document as CycloneDX XML, for consumers (Maven,
+	// other Java tooling) that don't speak JSON SBOMs.
+	FormatXML
+)
+
+// specVersion is the CycloneDX spec version apko emits.
+const specVersion = cdx.SpecVersion1_5
+
+type CycloneDX struct {
+	format Format
+}
 
+// CycloneDX implements generator.Generator.
+var _ generator.Generator = (*CycloneDX)(nil)
+
+// New returns a CycloneDX generator that emits JSON documents.
 func New() CycloneDX {
-	return CycloneDX{}
+	return CycloneDX{format: FormatJSON}
+}
+
+// NewXML returns a CycloneDX generator that emits XML documents.
+func NewXML() CycloneDX {
+	return CycloneDX{format: FormatXML}
 }
 
-func (cdx *CycloneDX) Key() string {
+func (c *CycloneDX) Key() string {
 	return "cyclonedx"
 }
 
-func (cdx *CycloneDX) Ext() string {
-	return "cdx"
+// Ext returns the file extension to use for the rendered document, which
+// depends on the serialization the generator was constructed with.
+func (c *CycloneDX) Ext() string {
+	if c.format == FormatXML {
+		return "cdx.xml"
+	}
+	return "cdx.json"
 }
 
-// Generate writes a cyclondx sbom in path
-func (cdx *CycloneDX) Generate(opts *options.Options, path string) error {
-	pkgComponents := []Component{}
-	pkgDependencies := []Dependency{}
+// Generate writes a cyclonedx sbom in path
+func (c *CycloneDX) Generate(opts *options.Options, path string) error {
+	pkgComponents := []cdx.Component{}
+	pkgDependencies := []cdx.Dependency{}
 
 	mm := map[string]string{"arch": opts.ImageInfo.Arch.ToAPK()}
+	resolver := sbom.NewDependencyResolver(opts)
 
 	for _, pkg := range opts.Packages {
-		// add the component
-		c := Component{
-			BOMRef: purl.NewPackageURL(
-				"apk", opts.OS.ID, pkg.Name, pkg.Version,
-				purl.QualifiersFromMap(mm), "").String(),
+		ref := purl.NewPackageURL(
+			"apk", opts.OS.ID, pkg.Name, pkg.Version,
+			purl.QualifiersFromMap(mm), "").String()
+
+		comp := cdx.Component{
+			BOMRef:      ref,
+			Type:        cdx.ComponentTypeOS,
 			Name:        pkg.Name,
 			Version:     pkg.Version,
 			Description: pkg.Description,
-			Licenses: []License{
-				{
-					Expression: pkg.License,
-				},
-			},
-			PUrl: purl.NewPackageURL(
-				"apk", opts.OS.ID, pkg.Name, pkg.Version,
-				purl.QualifiersFromMap(mm), "").String(),
-			// TODO(kaniini): Talk with CycloneDX people about adding "package" type.
-			Type: "operating-system",
+			PackageURL:  ref,
 		}
 
-		pkgComponents = append(pkgComponents, c)
-
-		// walk the dependency list
-		depRefs := []string{}
-		for _, dep := range pkg.Dependencies {
-			// TODO(kaniini): Properly handle virtual dependencies...
-			if strings.ContainsRune(dep, ':') {
-				continue
+		if pkg.License != "" {
+			comp.Licenses = &cdx.Licenses{
+				{Expression: pkg.License},
 			}
+		}
 
-			i := strings.IndexAny(dep, " ~<>=/!")
-			if i > -1 {
-				dep = dep[:i]
+		if pkg.ChecksumSHA256 != "" {
+			comp.Hashes = &[]cdx.Hash{
+				{Algorithm: cdx.HashAlgoSHA256, Value: pkg.ChecksumSHA256},
 			}
-			if dep == "" {
-				continue
+		}
+
+		if pkg.URL != "" {
+			comp.ExternalReferences = &[]cdx.ExternalReference{
+				{Type: websiteExternalReferenceType, URL: pkg.URL},
 			}
+		}
 
-			depRefs = append(depRefs, purl.NewPackageURL("apk", opts.OS.ID, dep, "",
-				purl.QualifiersFromMap(mm), "").String())
+		if len(pkg.Files) > 0 {
+			fileComponents := make([]cdx.Component, 0, len(pkg.Files))
+			occurrences := make([]cdx.EvidenceOccurrence, 0, len(pkg.Files))
+			for _, f := range pkg.Files {
+				fc := cdx.Component{
+					BOMRef: purl.NewPackageURL(
+						"apk", opts.OS.ID, pkg.Name, pkg.Version,
+						purl.QualifiersFromMap(mm), f.Path).String(),
+					Type: cdx.ComponentTypeFile,
+					Name: f.Path,
+				}
+
+				var hashes []cdx.Hash
+				if f.SHA256 != "" {
+					hashes = append(hashes, cdx.Hash{Algorithm: cdx.HashAlgoSHA256, Value: f.SHA256})
+				}
+				if f.SHA1 != "" {
+					hashes = append(hashes, cdx.Hash{Algorithm: cdx.HashAlgoSHA1, Value: f.SHA1})
+				}
+				if len(hashes) > 0 {
+					fc.Hashes = &hashes
+				}
+
+				fileComponents = append(fileComponents, fc)
+				occurrences = append(occurrences, cdx.EvidenceOccurrence{Location: f.Path})
+			}
+			sortComponents(fileComponents)
+			comp.Components = &fileComponents
+
+			// Record the files themselves as evidence of where this
+			// package's "component" was actually found on disk, per the
+			// CycloneDX evidence model.
+			sort.Slice(occurrences, func(i, j int) bool {
+				return occurrences[i].Location < occurrences[j].Location
+			})
+			comp.Evidence = &cdx.Evidence{Occurrences: &occurrences}
 		}
 
-		d := Dependency{
-			Ref: purl.NewPackageURL(
-				"apk", opts.OS.ID, pkg.Name, pkg.Version,
-				purl.QualifiersFromMap(mm), "").String(),
-			DependsOn: depRefs,
+		// walk the dependency list, resolving virtual deps (so:, cmd:, pc:)
+		// to the concrete package that provides them.
+		depRefs := []string{}
+		var unresolved []cdx.Property
+		for _, dep := range pkg.Dependencies {
+			resolved, ok := resolver.Resolve(dep)
+			if !ok {
+				// Record it instead of silently dropping it so the SBOM
+				// still reflects that the dependency was declared.
+				unresolved = append(unresolved, cdx.Property{
+					Name:  unresolvedDependencyProperty,
+					Value: dep,
+				})
+				continue
+			}
+			depRefs = append(depRefs, resolved)
+		}
+		if len(unresolved) > 0 {
+			comp.Properties = &unresolved
 		}
-		pkgDependencies = append(pkgDependencies, d)
+
+		pkgComponents = append(pkgComponents, comp)
+
+		sort.Strings(depRefs)
+		pkgDependencies = append(pkgDependencies, cdx.Dependency{
+			Ref:          ref,
+			Dependencies: &depRefs,
+		})
 	}
 
+	sortComponents(pkgComponents)
+	sortDependencies(pkgDependencies)
+
 	// Main package purl qualifiers
 	mmMain := map[string]string{}
 	if opts.ImageInfo.Repository != "" {
@@ -107,105 +209,76 @@ func (cdx *CycloneDX) Generate(opts *options.Options, path string) error {
 	if opts.ImageInfo.Arch.String() != "" {
 		mmMain["arch"] = opts.ImageInfo.Arch.ToOCIPlatform().Architecture
 	}
-	var imageComponent Component
-	layerComponent := Component{
+
+	var imageComponent cdx.Component
+	layerComponent := cdx.Component{
 		BOMRef: purl.NewPackageURL(
 			purl.TypeOCI, "", opts.ImageInfo.Name, opts.ImageInfo.LayerDigest,
 			purl.QualifiersFromMap(mmMain), "",
 		).String(),
 		Name:        opts.OS.Name,
-		Description: "apko OS layer",
-		PUrl: purl.NewPackageURL(
+		Description: osLayerDescription,
+		PackageURL: purl.NewPackageURL(
 			purl.TypeOCI, "", opts.ImageInfo.Name, opts.ImageInfo.LayerDigest,
 			purl.QualifiersFromMap(mmMain), "",
 		).String(),
 		Version:    opts.OS.Version,
-		Type:       "operating-system",
-		Components: pkgComponents,
+		Type:       cdx.ComponentTypeOS,
+		Components: &pkgComponents,
 	}
 
 	if opts.ImageInfo.ImageDigest != "" {
-		imageComponent = Component{
+		imageComponent = cdx.Component{
 			BOMRef: purl.NewPackageURL(
 				purl.TypeOCI, "", opts.ImageInfo.Name, opts.ImageInfo.ImageDigest,
 				purl.QualifiersFromMap(mmMain), "",
 			).String(),
-			Type: "container",
-			Name: "",
-			// Version:            "",
-			Description: "apko container image",
-			PUrl: purl.NewPackageURL(
+			Type:        cdx.ComponentTypeContainer,
+			Name:        "",
+			Description: imageComponentDescription,
+			PackageURL: purl.NewPackageURL(
 				purl.TypeOCI, "", opts.ImageInfo.Name, opts.ImageInfo.ImageDigest,
 				purl.QualifiersFromMap(mmMain), "",
 			).String(),
-			Components: []Component{layerComponent},
+			Components: &[]cdx.Component{layerComponent},
 		}
 	}
 
-	bom := Document{
-		BOMFormat:    "CycloneDX",
-		SpecVersion:  "1.4",
-		Version:      1,
-		Dependencies: pkgDependencies,
+	bom := cdx.NewBOM()
+	bom.SpecVersion = specVersion
+	bom.Metadata = &cdx.Metadata{
+		Timestamp: sourceDateEpoch(opts).UTC().Format(time.RFC3339),
+		Authors: &[]cdx.OrganizationalContact{
+			{Name: "apko"},
+		},
+		Tools: &cdx.ToolsChoice{
+			Components: &[]cdx.Component{
+				{
+					Type:   cdx.ComponentTypeApplication,
+					Name:   "apko",
+					Vendor: "Chainguard, Inc.",
+				},
+			},
+		},
 	}
+	bom.Dependencies = &pkgDependencies
 
 	if opts.ImageInfo.ImageDigest != "" {
-		bom.Components = []Component{imageComponent}
+		bom.Components = &[]cdx.Component{imageComponent}
+		bom.Metadata.Component = &imageComponent
 	} else {
-		bom.Components = []Component{layerComponent}
+		bom.Components = &[]cdx.Component{layerComponent}
+		bom.Metadata.Component = &layerComponent
 	}
 
-	if err := renderDoc(&bom, path); err != nil {
+	if err := c.renderDoc(bom, path); err != nil {
 		return fmt.Errorf("rendering sbom to disk: %w", err)
 	}
 
 	return nil
 }
 
-// TODO(kaniini): Move most of this over to gitlab.alpinelinux.org/alpine/go.
-type Document struct {
-	BOMFormat    string       `json:"bomFormat"`
-	SpecVersion  string       `json:"specVersion"`
-	Version      int          `json:"version"`
-	Components   []Component  `json:"components,omitempty"`
-	Dependencies []Dependency `json:"dependencies,omitempty"`
-}
-
-type Component struct {
-	BOMRef             string              `json:"bom-ref"`
-	Type               string              `json:"type"`
-	Name               string              `json:"name"`
-	Version            string              `json:"version"`
-	Description        string              `json:"description"`
-	PUrl               string              `json:"purl"`
-	Hashes             []Hash              `json:"hashes,omitempty"`
-	ExternalReferences []ExternalReference `json:"externalReferences,omitempty"`
-	Licenses           []License           `json:"licenses,omitempty"`
-	Components         []Component         `json:"components,omitempty"`
-}
-
-type License struct {
-	Expression string `json:"expression"`
-}
-
-type ExternalReference struct {
-	URL  string `json:"url"`
-	Type string `json:"type"`
-}
-
-type Dependency struct {
-	Ref       string   `json:"ref"`
-	DependsOn []string `json:"dependsOn"`
-}
-
-type HashAlgorithm string
-
-type Hash struct {
-	Algorithm HashAlgorithm `json:"alg"`
-	Value     string        `json:"content"`
-}
-
-func (cdx *CycloneDX) GenerateIndex(opts *options.Options, path string) error {
+func (c *CycloneDX) GenerateIndex(opts *options.Options, path string) error {
 	indexComponentName := opts.ImageInfo.IndexDigest.DeepCopy().String()
 	repoName := "index"
 	if opts.ImageInfo.Name != "" {
@@ -229,52 +302,108 @@ func (cdx *CycloneDX) GenerateIndex(opts *options.Options, path string) error {
 	}
 
 	purlString := purl.NewPackageURL(
-		purl.TypeOCI, "", repoName, opts.ImageInfo.ImageDigest,
+		purl.TypeOCI, "", repoName, opts.ImageInfo.IndexDigest.DeepCopy().String(),
 		purl.QualifiersFromMap(mmMain), "",
 	).String()
 
-	indexComponent := Component{
+	indexComponent := cdx.Component{
 		BOMRef:      purlString,
-		Type:        "container",
+		Type:        cdx.ComponentTypeContainer,
 		Name:        indexComponentName,
 		Version:     opts.ImageInfo.IndexDigest.DeepCopy().Hex,
 		Description: "Multi-arch image index",
-		PUrl:        purlString,
-		Hashes: []Hash{
+		PackageURL:  purlString,
+		Hashes: &[]cdx.Hash{
 			{
-				Algorithm: "SHA-256",
+				Algorithm: cdx.HashAlgoSHA256,
 				Value:     opts.ImageInfo.IndexDigest.DeepCopy().Hex,
 			},
 		},
-		Components: []Component{},
+		Components: &[]cdx.Component{},
 	}
 
-	// Add the images as subcomponents
+	// Add the images as subcomponents, folding each arch's already-generated
+	// CycloneDX document (its package components and their dependencies)
+	// in under the matching child rather than re-describing the arch by
+	// digest alone.
+	children := *indexComponent.Components
+	indexDependencies := []cdx.Dependency{}
 	for _, info := range opts.ImageInfo.Images {
-		indexComponent.Components = append(
-			indexComponent.Components, cdx.archImageComponent(opts, info),
-		)
+		child, deps := c.archImageComponent(opts, info)
+		children = append(children, child)
+		indexDependencies = append(indexDependencies, deps...)
 	}
-
-	bom := Document{
-		BOMFormat:   "CycloneDX",
-		SpecVersion: "1.4",
-		Version:     1,
-		Components: []Component{
-			indexComponent,
+	sortComponents(children)
+	sortDependencies(indexDependencies)
+	indexComponent.Components = &children
+
+	bom := cdx.NewBOM()
+	bom.SpecVersion = specVersion
+	bom.Metadata = &cdx.Metadata{
+		Timestamp: sourceDateEpoch(opts).UTC().Format(time.RFC3339),
+		Authors: &[]cdx.OrganizationalContact{
+			{Name: "apko"},
 		},
-		Dependencies: []Dependency{},
+		Component: &indexComponent,
 	}
+	bom.Components = &[]cdx.Component{indexComponent}
+	bom.Dependencies = &indexDependencies
 
-	if err := renderDoc(&bom, path); err != nil {
+	if err := c.renderDoc(bom, path); err != nil {
 		return fmt.Errorf("rendering SBOM: %w", err)
 	}
 
 	return nil
 }
 
-// imageComponent takes an image and returns a component representing it
-func (cdx *CycloneDX) archImageComponent(opts *options.Options, info options.ArchImageInfo) Component {
+// GenerateAttestation wraps the CycloneDX document previously rendered at
+// sbomPath as an in-toto attestation at outPath, DSSE-signed when
+// opts.Attestation.Backend selects a signer, so `apko publish` can attach it
+// to the image as an OCI referrer. It is a no-op when attestations aren't
+// enabled on opts.
+func (c *CycloneDX) GenerateAttestation(opts *options.Options, sbomPath, outPath string) error {
+	if !opts.Attestation.Enabled {
+		return nil
+	}
+
+	signer, err := attestation.NewSigner(opts.Attestation)
+	if err != nil {
+		return fmt.Errorf("configuring attestation signer: %w", err)
+	}
+
+	if err := attestation.Generate(opts, attestation.Options{
+		PredicateType: attestation.CycloneDXPredicateType,
+		Signer:        signer,
+	}, sbomPath, outPath); err != nil {
+		return fmt.Errorf("generating attestation: %w", err)
+	}
+
+	return nil
+}
+
+// platformPropertyPrefix namespaces the properties archImageComponent uses to
+// record a child's OCI platform (os/architecture/variant), mirroring the
+// "platform" block the OCI image-index spec puts on each manifest descriptor
+// but that CycloneDX 1.5 components have no native field for.
+const platformPropertyPrefix = "apko:platform:"
+
+// bomExternalReferenceType is the CycloneDX external reference type for a
+// pointer to a sibling BOM document, used when an arch's CycloneDX document
+// is only available on disk rather than in memory.
+const bomExternalReferenceType = cdx.ExternalReferenceType("bom")
+
+// websiteExternalReferenceType is the CycloneDX external reference type used
+// to record a package's upstream homepage (options.Package.URL).
+const websiteExternalReferenceType = cdx.ExternalReferenceType("website")
+
+// archImageComponent takes one arch's image and returns the Component
+// representing it in the index document, along with the dependency entries
+// from its already-generated CycloneDX document (if any) so the caller can
+// fold them into the index's top-level Dependencies. The platform used for
+// purl qualifiers and properties is always info.Arch's own
+// ToOCIPlatform(), never the parent opts.ImageInfo.Arch, so a multi-arch
+// index doesn't repeat one arch's qualifiers across every child.
+func (c *CycloneDX) archImageComponent(opts *options.Options, info options.ArchImageInfo) (cdx.Component, []cdx.Dependency) {
 	repoName := ""
 	if opts.ImageInfo.Name != "" {
 		ref, err := name.ParseReference(opts.ImageInfo.Name)
@@ -288,18 +417,18 @@ func (cdx *CycloneDX) archImageComponent(opts *options.Options, info options.Arc
 		imageRepoName = repoName
 	}
 
+	platform := info.Arch.ToOCIPlatform()
+
 	mmMain := map[string]string{}
 	if opts.ImageInfo.Repository != "" {
 		mmMain["repository_url"] = opts.ImageInfo.Repository
 	}
-	if opts.ImageInfo.Arch.String() != "" {
-		mmMain["arch"] = opts.ImageInfo.Arch.ToOCIPlatform().Architecture
+	if platform.Architecture != "" {
+		mmMain["arch"] = platform.Architecture
 	}
-
-	if opts.ImageInfo.Arch.ToOCIPlatform().OS != "" {
-		mmMain["os"] = opts.ImageInfo.Arch.ToOCIPlatform().OS
+	if platform.OS != "" {
+		mmMain["os"] = platform.OS
 	}
-
 	if opts.ImageInfo.IndexMediaType != "" {
 		mmMain["mediaType"] = string(opts.ImageInfo.IndexMediaType)
 	}
@@ -309,40 +438,175 @@ func (cdx *CycloneDX) archImageComponent(opts *options.Options, info options.Arc
 		purl.QualifiersFromMap(mmMain), "",
 	).String()
 
-	return Component{
+	comp := cdx.Component{
 		BOMRef: purlString,
-		Type:   "container",
+		Type:   cdx.ComponentTypeContainer,
 		Name:   info.Digest.DeepCopy().String(),
 		Description: fmt.Sprintf(
-			"apko image for %s/%s", info.Arch.ToOCIPlatform().OS, info.Arch,
+			"apko image for %s/%s", platform.OS, info.Arch,
 		),
-		PUrl:    purlString,
-		Version: info.Digest.DeepCopy().String(),
-		Hashes: []Hash{
+		PackageURL: purlString,
+		Version:    info.Digest.DeepCopy().String(),
+		Hashes: &[]cdx.Hash{
 			{
-				Algorithm: "SHA-256",
+				Algorithm: cdx.HashAlgoSHA256,
 				Value:     info.Digest.DeepCopy().Hex,
 			},
 		},
-		ExternalReferences: []ExternalReference{},
-		Licenses:           []License{},
-		Components:         []Component{},
+		Properties: platformProperties(platform),
+	}
+
+	var deps []cdx.Dependency
+	archBOM, err := loadArchBOM(info)
+	switch {
+	case err != nil:
+		// The sibling document couldn't be read back (e.g. it hasn't been
+		// written to its final location yet, or isn't valid CycloneDX): still
+		// point at where it lives rather than failing the whole index over
+		// one arch's SBOM.
+		comp.ExternalReferences = &[]cdx.ExternalReference{
+			{Type: bomExternalReferenceType, URL: info.BOMPath},
+		}
+	case archBOM != nil:
+		// Fold the arch's actual package list in under this child rather
+		// than nesting its whole document: archBOM.Components is the same
+		// OS-layer/image wrapper this component already describes by
+		// digest, so re-nesting it verbatim would just repeat that digest
+		// and hash one level down for no reason.
+		if packages := archPackageComponents(archBOM); packages != nil {
+			comp.Components = packages
+		}
+		if archBOM.Dependencies != nil {
+			deps = *archBOM.Dependencies
+		}
+	}
+
+	return comp, deps
+}
+
+// archPackageComponents drills past the osLayerDescription/
+// imageComponentDescription wrapper components an arch's own CycloneDX
+// document (as Generate produces it) nests its package list under, and
+// returns just that package list.
+func archPackageComponents(archBOM *cdx.BOM) *[]cdx.Component {
+	if archBOM.Components == nil || len(*archBOM.Components) != 1 {
+		return archBOM.Components
+	}
+
+	root := (*archBOM.Components)[0]
+	if root.Description == imageComponentDescription && root.Components != nil && len(*root.Components) == 1 {
+		root = (*root.Components)[0]
+	}
+	if root.Description == osLayerDescription {
+		return root.Components
 	}
+
+	return archBOM.Components
+}
+
+// platformProperties records an OCI platform as CycloneDX properties, one
+// per field, following the same pattern unresolvedDependencyProperty uses
+// for data the spec has no native field for.
+func platformProperties(platform v1.Platform) *[]cdx.Property {
+	props := []cdx.Property{}
+	if platform.OS != "" {
+		props = append(props, cdx.Property{Name: platformPropertyPrefix + "os", Value: platform.OS})
+	}
+	if platform.Architecture != "" {
+		props = append(props, cdx.Property{Name: platformPropertyPrefix + "architecture", Value: platform.Architecture})
+	}
+	if platform.Variant != "" {
+		props = append(props, cdx.Property{Name: platformPropertyPrefix + "variant", Value: platform.Variant})
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return &props
 }
 
-// renderDoc marshals a document to json and writes it to disk
-func renderDoc(doc *Document, path string) error {
+// loadArchBOM reads and decodes the CycloneDX document at info.BOMPath. It
+// returns a nil BOM, not an error, when BOMPath is empty: the caller falls
+// back to the digest-only component apko has always emitted.
+func loadArchBOM(info options.ArchImageInfo) (*cdx.BOM, error) {
+	if info.BOMPath == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(info.BOMPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", info.BOMPath, err)
+	}
+	defer f.Close()
+
+	bom := new(cdx.BOM)
+	if err := cdx.NewBOMDecoder(f, cdx.BOMFileFormatJSON).Decode(bom); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", info.BOMPath, err)
+	}
+	return bom, nil
+}
+
+// renderDoc marshals a document and writes it to disk in the generator's
+// configured format.
+func (c *CycloneDX) renderDoc(doc *cdx.BOM, path string) error {
 	out, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("opening SBOM path %s for writing: %w", path, err)
 	}
 	defer out.Close()
 
-	enc := json.NewEncoder(out)
-	enc.SetIndent("", "  ")
+	fileFormat := cdx.BOMFileFormatJSON
+	if c.format == FormatXML {
+		fileFormat = cdx.BOMFileFormatXML
+	}
+
+	enc := cdx.NewBOMEncoder(out, fileFormat)
+	enc.SetPretty(true)
 
 	if err := enc.Encode(doc); err != nil {
-		return fmt.Errorf("encoding spdx sbom: %w", err)
+		return fmt.Errorf("encoding cyclonedx sbom: %w", err)
 	}
 	return nil
 }
+
+// sourceDateEpoch resolves the timestamp to stamp onto metadata.timestamp
+// according to opts.SourceDateEpochMode, so that repeated runs against the
+// same inputs produce byte-identical documents.
+func sourceDateEpoch(opts *options.Options) time.Time {
+	switch opts.SourceDateEpochMode {
+	case options.SourceDateEpochZero:
+		return time.Unix(0, 0)
+	case options.SourceDateEpochBuild:
+		return time.Now()
+	default: // options.SourceDateEpochSource
+		if !opts.SourceDateEpoch.IsZero() {
+			return opts.SourceDateEpoch
+		}
+		// Fall back to the newest APK's build time so the document is still
+		// reproducible even when no SOURCE_DATE_EPOCH was configured. An
+		// index-level Options has no Packages of its own (they live on each
+		// arch's own Options), so fall back further still, to the Unix
+		// epoch, rather than leaving newest at the Go zero time.
+		newest := time.Unix(0, 0)
+		for _, pkg := range opts.Packages {
+			if pkg.BuildTime.After(newest) {
+				newest = pkg.BuildTime
+			}
+		}
+		return newest
+	}
+}
+
+// sortComponents orders components deterministically by BOM-ref so that
+// documents generated from the same inputs are byte-for-byte identical.
+func sortComponents(components []cdx.Component) {
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].BOMRef < components[j].BOMRef
+	})
+}
+
+// sortDependencies orders dependency entries deterministically by ref.
+func sortDependencies(deps []cdx.Dependency) {
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Ref < deps[j].Ref
+	})
+}
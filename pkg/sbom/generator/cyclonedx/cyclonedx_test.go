@@ -0,0 +1,215 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cyclonedx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+func testOptions(pkgs ...*options.Package) *options.Options {
+	opts := &options.Options{Packages: pkgs}
+	opts.OS.ID = "alpine"
+	opts.OS.Name = "Alpine Linux"
+	opts.ImageInfo.Name = "cgr.dev/chainguard/static"
+	opts.ImageInfo.LayerDigest = "sha256:deadbeef"
+	return opts
+}
+
+func generate(t *testing.T, opts *options.Options) *cdx.BOM {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sbom.cdx.json")
+	c := New()
+	if err := c.Generate(opts, path); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := new(cdx.BOM)
+	if err := json.Unmarshal(raw, bom); err != nil {
+		t.Fatalf("decoding generated SBOM: %v", err)
+	}
+	return bom
+}
+
+// packageComponent returns the single package component Generate emitted,
+// failing the test if the document's shape doesn't match what Generate is
+// expected to produce (one layer wrapping exactly one package).
+func packageComponent(t *testing.T, bom *cdx.BOM) cdx.Component {
+	t.Helper()
+	if bom.Components == nil || len(*bom.Components) != 1 {
+		t.Fatalf("Components = %v, want exactly one (the OS layer)", bom.Components)
+	}
+	layer := (*bom.Components)[0]
+	if layer.Components == nil || len(*layer.Components) != 1 {
+		t.Fatalf("layer Components = %v, want exactly one package", layer.Components)
+	}
+	return (*layer.Components)[0]
+}
+
+func TestGeneratePackageChecksum(t *testing.T) {
+	opts := testOptions(&options.Package{
+		Name:           "busybox",
+		Version:        "1.36.1-r2",
+		ChecksumSHA256: "abc123",
+	})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Hashes == nil || len(*pkg.Hashes) != 1 {
+		t.Fatalf("Hashes = %v, want exactly one", pkg.Hashes)
+	}
+	got := (*pkg.Hashes)[0]
+	if got.Algorithm != cdx.HashAlgoSHA256 || got.Value != "abc123" {
+		t.Errorf("Hash = %+v, want {SHA-256 abc123}", got)
+	}
+}
+
+func TestGenerateFileComponents(t *testing.T) {
+	opts := testOptions(&options.Package{
+		Name:    "busybox",
+		Version: "1.36.1-r2",
+		Files: []options.File{
+			{Path: "/bin/busybox", SHA256: "filehash256", SHA1: "filehash1"},
+		},
+	})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Components == nil || len(*pkg.Components) != 1 {
+		t.Fatalf("file Components = %v, want exactly one", pkg.Components)
+	}
+	file := (*pkg.Components)[0]
+	if file.Type != cdx.ComponentTypeFile || file.Name != "/bin/busybox" {
+		t.Errorf("file component = %+v, want type file named /bin/busybox", file)
+	}
+	if file.Hashes == nil || len(*file.Hashes) != 2 {
+		t.Fatalf("file Hashes = %v, want sha256 and sha1", file.Hashes)
+	}
+}
+
+func TestGenerateNoFiles(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Components != nil {
+		t.Errorf("Components = %v, want nil when the package has no files", pkg.Components)
+	}
+}
+
+func TestGeneratePackageLicense(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2", License: "MIT"})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Licenses == nil || len(*pkg.Licenses) != 1 {
+		t.Fatalf("Licenses = %v, want exactly one", pkg.Licenses)
+	}
+	if (*pkg.Licenses)[0].Expression != "MIT" {
+		t.Errorf("Licenses = %+v, want MIT", *pkg.Licenses)
+	}
+}
+
+func TestGenerateNoLicenseNoLicenses(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Licenses != nil {
+		t.Errorf("Licenses = %v, want nil when the package has no License, not an empty SPDX expression", pkg.Licenses)
+	}
+}
+
+func TestGenerateFileEvidenceOccurrences(t *testing.T) {
+	opts := testOptions(&options.Package{
+		Name:    "busybox",
+		Version: "1.36.1-r2",
+		Files: []options.File{
+			{Path: "/usr/bin/busybox", SHA256: "filehash256"},
+			{Path: "/bin/busybox", SHA256: "filehash256"},
+		},
+	})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Evidence == nil || pkg.Evidence.Occurrences == nil || len(*pkg.Evidence.Occurrences) != 2 {
+		t.Fatalf("Evidence.Occurrences = %v, want exactly two", pkg.Evidence)
+	}
+	occurrences := *pkg.Evidence.Occurrences
+	if occurrences[0].Location != "/bin/busybox" || occurrences[1].Location != "/usr/bin/busybox" {
+		t.Errorf("Occurrences = %+v, want sorted by location", occurrences)
+	}
+}
+
+func TestGenerateNoFilesNoEvidence(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.Evidence != nil {
+		t.Errorf("Evidence = %v, want nil when the package has no files", pkg.Evidence)
+	}
+}
+
+func TestGeneratePackageURL(t *testing.T) {
+	opts := testOptions(&options.Package{
+		Name:    "busybox",
+		Version: "1.36.1-r2",
+		URL:     "https://busybox.net",
+	})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.ExternalReferences == nil || len(*pkg.ExternalReferences) != 1 {
+		t.Fatalf("ExternalReferences = %v, want exactly one", pkg.ExternalReferences)
+	}
+	got := (*pkg.ExternalReferences)[0]
+	if got.Type != websiteExternalReferenceType || got.URL != "https://busybox.net" {
+		t.Errorf("ExternalReference = %+v, want {%s https://busybox.net}", got, websiteExternalReferenceType)
+	}
+}
+
+func TestGenerateNoURLNoExternalReferences(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	pkg := packageComponent(t, generate(t, opts))
+
+	if pkg.ExternalReferences != nil {
+		t.Errorf("ExternalReferences = %v, want nil when the package has no URL", pkg.ExternalReferences)
+	}
+}
+
+func TestGenerateMetadataAuthors(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	bom := generate(t, opts)
+
+	if bom.Metadata == nil || bom.Metadata.Authors == nil || len(*bom.Metadata.Authors) != 1 {
+		t.Fatalf("Metadata.Authors = %v, want exactly one", bom.Metadata)
+	}
+	if (*bom.Metadata.Authors)[0].Name != "apko" {
+		t.Errorf("Authors = %+v, want apko", *bom.Metadata.Authors)
+	}
+}
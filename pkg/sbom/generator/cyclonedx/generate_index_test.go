@@ -0,0 +1,174 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cyclonedx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+func TestGenerateIndexFoldsArchPackages(t *testing.T) {
+	dir := t.TempDir()
+
+	archOpts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+	archOpts.ImageInfo.Arch = "x86_64"
+	archOpts.ImageInfo.ImageDigest = "sha256:" + testDigestHex("a")
+
+	archBOMPath := filepath.Join(dir, "x86_64.cdx.json")
+	c := New()
+	if err := c.Generate(archOpts, archBOMPath); err != nil {
+		t.Fatalf("Generate (arch): %v", err)
+	}
+
+	indexOpts := testOptions()
+	indexOpts.ImageInfo.IndexDigest = v1.Hash{Algorithm: "sha256", Hex: testDigestHex("b")}
+	indexOpts.ImageInfo.Images = []options.ArchImageInfo{
+		{
+			Digest:  v1.Hash{Algorithm: "sha256", Hex: testDigestHex("a")},
+			Arch:    "x86_64",
+			BOMPath: archBOMPath,
+		},
+	}
+
+	indexPath := filepath.Join(dir, "index.cdx.json")
+	if err := c.GenerateIndex(indexOpts, indexPath); err != nil {
+		t.Fatalf("GenerateIndex: %v", err)
+	}
+
+	raw, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := new(cdx.BOM)
+	if err := json.Unmarshal(raw, bom); err != nil {
+		t.Fatalf("decoding index: %v", err)
+	}
+
+	if bom.Components == nil || len(*bom.Components) != 1 {
+		t.Fatalf("Components = %v, want exactly one (the index)", bom.Components)
+	}
+	index := (*bom.Components)[0]
+	if index.Components == nil || len(*index.Components) != 1 {
+		t.Fatalf("index Components = %v, want exactly one child (x86_64)", index.Components)
+	}
+	child := (*index.Components)[0]
+
+	// The child should describe the arch's package list directly - not
+	// re-nest the arch's own OS-layer/image wrapper, which would just
+	// repeat the digest and hash already on child.
+	if child.Components == nil || len(*child.Components) != 1 {
+		t.Fatalf("child Components = %v, want exactly one (the busybox package)", child.Components)
+	}
+	pkg := (*child.Components)[0]
+	if pkg.Name != "busybox" || pkg.Description == osLayerDescription || pkg.Description == imageComponentDescription {
+		t.Errorf("child's package = %+v, want the flattened busybox component, not a re-nested wrapper", pkg)
+	}
+
+	if child.Licenses != nil {
+		t.Errorf("child Licenses = %v, want nil when the arch's document was folded in successfully", child.Licenses)
+	}
+}
+
+// TestGenerateIndexComponentURLUsesIndexDigest guards against the index
+// component's purl being built from opts.ImageInfo.ImageDigest, which is
+// never set on an index-level Options (only each arch's own Options has an
+// ImageDigest) and would leave the index's BOMRef/PackageURL with no digest
+// at all.
+func TestGenerateIndexComponentURLUsesIndexDigest(t *testing.T) {
+	indexOpts := testOptions()
+	indexOpts.ImageInfo.Name = "cgr.dev/chainguard/static"
+	indexOpts.ImageInfo.IndexDigest = v1.Hash{Algorithm: "sha256", Hex: testDigestHex("b")}
+
+	indexPath := filepath.Join(t.TempDir(), "index.cdx.json")
+	c := New()
+	if err := c.GenerateIndex(indexOpts, indexPath); err != nil {
+		t.Fatalf("GenerateIndex: %v", err)
+	}
+
+	raw, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := new(cdx.BOM)
+	if err := json.Unmarshal(raw, bom); err != nil {
+		t.Fatalf("decoding index: %v", err)
+	}
+	index := (*bom.Components)[0]
+
+	wantDigest := indexOpts.ImageInfo.IndexDigest.DeepCopy().String()
+	if index.PackageURL == "" || !strings.Contains(index.PackageURL, wantDigest) {
+		t.Errorf("PackageURL = %q, want it to reference the index digest %q", index.PackageURL, wantDigest)
+	}
+	if index.BOMRef != index.PackageURL {
+		t.Errorf("BOMRef = %q, want it to match PackageURL %q", index.BOMRef, index.PackageURL)
+	}
+}
+
+func TestGenerateIndexFallsBackToExternalReference(t *testing.T) {
+	indexOpts := testOptions()
+	indexOpts.ImageInfo.IndexDigest = v1.Hash{Algorithm: "sha256", Hex: testDigestHex("b")}
+	indexOpts.ImageInfo.Images = []options.ArchImageInfo{
+		{
+			Digest:  v1.Hash{Algorithm: "sha256", Hex: testDigestHex("a")},
+			Arch:    "x86_64",
+			BOMPath: filepath.Join(t.TempDir(), "does-not-exist.cdx.json"),
+		},
+	}
+
+	indexPath := filepath.Join(t.TempDir(), "index.cdx.json")
+	c := New()
+	if err := c.GenerateIndex(indexOpts, indexPath); err != nil {
+		t.Fatalf("GenerateIndex: %v", err)
+	}
+
+	raw, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := new(cdx.BOM)
+	if err := json.Unmarshal(raw, bom); err != nil {
+		t.Fatalf("decoding index: %v", err)
+	}
+
+	child := (*(*bom.Components)[0].Components)[0]
+	if child.ExternalReferences == nil || len(*child.ExternalReferences) != 1 {
+		t.Fatalf("ExternalReferences = %v, want one pointer to the unreadable sibling BOM", child.ExternalReferences)
+	}
+	if (*child.ExternalReferences)[0].Type != bomExternalReferenceType {
+		t.Errorf("ExternalReference.Type = %q, want %q", (*child.ExternalReferences)[0].Type, bomExternalReferenceType)
+	}
+	if child.Licenses != nil {
+		t.Errorf("child Licenses = %v, want nil", child.Licenses)
+	}
+	if child.Components != nil {
+		t.Errorf("child Components = %v, want nil when the sibling document couldn't be read", child.Components)
+	}
+}
+
+// testDigestHex returns a syntactically valid, distinguishable sha256 hex
+// digest for test fixtures, tagged with suffix so different fixtures don't
+// collide.
+func testDigestHex(suffix string) string {
+	base := "0000000000000000000000000000000000000000000000000000000000000000"
+	return base[:64-len(suffix)] + suffix
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cyclonedx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+// TestGenerateIndexTimestampDefaultsToEpoch guards against the
+// SourceDateEpochSource fallback leaving metadata.timestamp at the Go zero
+// time ("0001-01-01T00:00:00Z") when an index-level Options has no Packages
+// of its own to fall back to (they live on each arch's own Options).
+func TestGenerateIndexTimestampDefaultsToEpoch(t *testing.T) {
+	indexOpts := testOptions()
+	indexOpts.ImageInfo.IndexDigest = v1.Hash{Algorithm: "sha256", Hex: testDigestHex("b")}
+
+	indexPath := filepath.Join(t.TempDir(), "index.cdx.json")
+	c := New()
+	if err := c.GenerateIndex(indexOpts, indexPath); err != nil {
+		t.Fatalf("GenerateIndex: %v", err)
+	}
+
+	raw, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := new(cdx.BOM)
+	if err := json.Unmarshal(raw, bom); err != nil {
+		t.Fatalf("decoding index: %v", err)
+	}
+
+	want := "1970-01-01T00:00:00Z"
+	if bom.Metadata == nil || bom.Metadata.Timestamp != want {
+		t.Errorf("Metadata.Timestamp = %v, want %q", bom.Metadata, want)
+	}
+}
+
+func TestGenerateTimestampUsesConfiguredSourceDateEpoch(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+	opts.SourceDateEpoch = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	bom := generate(t, opts)
+
+	want := "2024-01-02T03:04:05Z"
+	if bom.Metadata == nil || bom.Metadata.Timestamp != want {
+		t.Errorf("Metadata.Timestamp = %v, want %q", bom.Metadata, want)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package generator declares the interface every SBOM format (cyclonedx,
+// spdx) implements, so callers like `apko publish` can generate and attest
+// documents without depending on a specific one.
+package generator
+
+import "chainguard.dev/apko/pkg/sbom/options"
+
+// Generator produces an SBOM document describing an apko build and,
+// optionally, an in-toto attestation wrapping it.
+type Generator interface {
+	// Key identifies the generator, e.g. "cyclonedx" or "spdx".
+	Key() string
+	// Ext returns the file extension to use for the rendered document.
+	Ext() string
+	// Generate writes a single-arch SBOM to path.
+	Generate(opts *options.Options, path string) error
+	// GenerateIndex writes a multi-arch index SBOM to path.
+	GenerateIndex(opts *options.Options, path string) error
+	// GenerateAttestation wraps the document at sbomPath as an in-toto
+	// attestation at outPath, DSSE-signed when a signing backend is
+	// configured. It is a no-op when attestations aren't enabled on opts.
+	GenerateAttestation(opts *options.Options, sbomPath, outPath string) error
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package options holds the inputs SBOM generators (cyclonedx, spdx) need to
+// describe an apko build: the resolved package set, the OS it targets, and
+// the image metadata produced once the build finishes. It intentionally has
+// no dependency on any one generator's document library, so the same
+// Options value drives every format apko can emit.
+package options
+
+import (
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// Architecture is the target architecture of a build, in apko's own naming
+// (e.g. "x86_64"). It knows how to render itself in the two other vocabularies
+// generators need: the apk package index's and the OCI image spec's.
+type Architecture string
+
+// String returns the architecture in apko's own naming.
+func (a Architecture) String() string {
+	return string(a)
+}
+
+// ToAPK returns the architecture as apk-tools spells it (e.g. "x86_64"),
+// which is how it appears in package purls.
+func (a Architecture) ToAPK() string {
+	return string(a)
+}
+
+// ToOCIPlatform returns the architecture as an OCI image-spec platform, for
+// generators that need the "os"/"architecture"/"variant" vocabulary instead.
+func (a Architecture) ToOCIPlatform() v1.Platform {
+	arch, variant, _ := archToOCI(string(a))
+	return v1.Platform{
+		OS:           "linux",
+		Architecture: arch,
+		Variant:      variant,
+	}
+}
+
+// archToOCI maps apk's architecture names to the OCI image-spec equivalents,
+// splitting out the variant for architectures that carry one (arm).
+func archToOCI(apkArch string) (arch, variant string, ok bool) {
+	switch apkArch {
+	case "x86_64":
+		return "amd64", "", true
+	case "x86":
+		return "386", "", true
+	case "aarch64":
+		return "arm64", "v8", true
+	case "armv7":
+		return "arm", "v7", true
+	case "":
+		return "", "", false
+	default:
+		return apkArch, "", true
+	}
+}
+
+// OS describes the distro an image is built from.
+type OS struct {
+	ID      string
+	Name    string
+	Version string
+}
+
+// ImageInfo carries the metadata apko has about the image it just built (or,
+// for GenerateIndex, the multi-arch index wrapping several such images).
+type ImageInfo struct {
+	// Name is the image reference the build was tagged as, e.g.
+	// "cgr.dev/chainguard/static:latest".
+	Name string
+	// Repository is the source repository the packages were pulled from.
+	Repository string
+	// Arch is the architecture this image (or, for an index, the parent
+	// options) was built for.
+	Arch Architecture
+	// LayerDigest is the digest of the single OS layer apko produced.
+	LayerDigest string
+	// ImageDigest is the digest of the per-arch image manifest, empty until
+	// the image has actually been built and pushed/loaded.
+	ImageDigest string
+	// IndexDigest is the digest of the multi-arch index, set only when
+	// generating an index-level SBOM.
+	IndexDigest v1.Hash
+	// IndexMediaType is the media type of the multi-arch index.
+	IndexMediaType types.MediaType
+	// Images holds one entry per arch included in a multi-arch build, used
+	// by GenerateIndex to describe each child image.
+	Images []ArchImageInfo
+}
+
+// ArchImageInfo describes one arch's image within a multi-arch build.
+type ArchImageInfo struct {
+	// Digest is the per-arch image manifest digest.
+	Digest v1.Hash
+	// Arch is this image's own architecture - never the parent ImageInfo's,
+	// since a multi-arch build's children each target a different one.
+	Arch Architecture
+	// BOMPath is the path to this arch's already-generated CycloneDX (or
+	// SPDX) document, if any. GenerateIndex uses it to fold the arch's
+	// package inventory into the index, falling back to an external
+	// reference to the path when the document can't be read back.
+	//
+	// This is a path rather than an in-memory document handle so that
+	// ArchImageInfo stays generator-agnostic: a *cdx.BOM field here would
+	// tie every format's index generation to the CycloneDX library.
+	BOMPath string
+}
+
+// File describes a single file an APK package installs, as needed to emit a
+// file-level SBOM component with its own hash.
+type File struct {
+	// Path is the file's install path, e.g. "/usr/bin/busybox".
+	Path string
+	// SHA256 is the file's sha256 checksum, hex-encoded.
+	SHA256 string
+	// SHA1 is the file's sha1 checksum, hex-encoded, for package formats
+	// that only record the weaker hash.
+	SHA1 string
+}
+
+// Package is a single resolved APK package going into the image.
+type Package struct {
+	Name        string
+	Version     string
+	Description string
+	License     string
+	// URL is the package's upstream homepage, as recorded in the APKINDEX
+	// "url" field. Generators that support it add this as an external
+	// reference on the package's component.
+	URL string
+	// Origin is the source package this binary package was split from, e.g.
+	// multiple "origin: openssl" packages for openssl, openssl-dev, etc.
+	Origin string
+	// Dependencies are the raw "depend" tokens apk-tools would resolve at
+	// install time (plain names, "so:", "cmd:", or "pc:" virtuals).
+	Dependencies []string
+	// Provides are the raw tokens this package satisfies for other
+	// packages' Dependencies, in the same vocabulary.
+	Provides []string
+	// ProviderPriority is apk-tools' provider_priority: when more than one
+	// installed package provides the same token, the highest priority wins,
+	// with package name as the deterministic tie-breaker.
+	ProviderPriority int
+	// ChecksumSHA256 is the package's own sha256 checksum, hex-encoded.
+	ChecksumSHA256 string
+	// Files lists the package's payload files, for generators that emit a
+	// file-level component per installed file.
+	Files []File
+	// BuildTime is when the APK was built, used as a SourceDateEpoch
+	// fallback when no SOURCE_DATE_EPOCH was configured for the image build.
+	BuildTime time.Time
+}
+
+// SourceDateEpochMode selects how a generator resolves the timestamp it
+// stamps onto a document's metadata, so that repeated runs against the same
+// inputs can produce byte-identical output.
+type SourceDateEpochMode int
+
+const (
+	// SourceDateEpochSource uses Options.SourceDateEpoch when set, falling
+	// back to the newest package's BuildTime. This is the default.
+	SourceDateEpochSource SourceDateEpochMode = iota
+	// SourceDateEpochBuild stamps the actual time the document was
+	// generated, i.e. non-reproducible, build-time-of-day output.
+	SourceDateEpochBuild
+	// SourceDateEpochZero always stamps the Unix epoch, for callers that
+	// want a fixed timestamp regardless of build inputs.
+	SourceDateEpochZero
+)
+
+// AttestationBackend selects how GenerateAttestation signs the envelope it
+// produces.
+type AttestationBackend string
+
+const (
+	// AttestationBackendNone leaves the attestation unsigned: only the
+	// in-toto statement is written to disk. This is the default.
+	AttestationBackendNone AttestationBackend = ""
+	// AttestationBackendKey signs with a file-based key at KeyPath.
+	AttestationBackendKey AttestationBackend = "key"
+	// AttestationBackendKeyless signs through Fulcio using an ambient OIDC
+	// identity, the same flow `cosign sign --keyless` uses. The caller must
+	// supply the resulting signer via KeylessSigner: obtaining a short-lived
+	// Fulcio certificate needs network access and a credential provider,
+	// which belongs in the cmd layer (apko publish), not pkg/sbom.
+	AttestationBackendKeyless AttestationBackend = "keyless"
+)
+
+// AttestationOptions configures whether and how a generator's
+// GenerateAttestation signs the SBOM it wraps.
+type AttestationOptions struct {
+	// Enabled turns on attestation generation. When false,
+	// GenerateAttestation is a no-op.
+	Enabled bool
+	// Backend selects the signing backend.
+	Backend AttestationBackend
+	// KeyPath is the path to the PKCS#8 PEM-encoded ed25519 private key to
+	// sign with, used when
+	// Backend is AttestationBackendKey.
+	KeyPath string
+	// KeylessSigner is the Fulcio-backed signer to use when Backend is
+	// AttestationBackendKeyless. Required in that case: see
+	// AttestationBackendKeyless.
+	KeylessSigner dsse.SignVerifier
+}
+
+// Options is everything an SBOM generator needs to describe one apko build.
+type Options struct {
+	// Packages is the resolved package set going into the image.
+	Packages []*Package
+	// OS is the distro the image is built from.
+	OS OS
+	// ImageInfo is the metadata apko has about the built image.
+	ImageInfo ImageInfo
+	// SourceDateEpoch is the configured SOURCE_DATE_EPOCH, used when
+	// SourceDateEpochMode is SourceDateEpochSource.
+	SourceDateEpoch time.Time
+	// SourceDateEpochMode selects how the document's timestamp is resolved.
+	SourceDateEpochMode SourceDateEpochMode
+	// Attestation configures whether GenerateAttestation signs the SBOMs
+	// this build produces.
+	Attestation AttestationOptions
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"strings"
+
+	purl "github.com/package-url/packageurl-go"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+// DependencyResolver rewrites the virtual dependency tokens apk uses
+// (so:libfoo.so.1, cmd:sh, pc:openssl, or a plain provides name) into the
+// purl of the installed package that actually satisfies them, mirroring how
+// apk-tools resolves virtuals at install time. Its choice of provider is
+// deterministic, so resolvers built from the same Options agree on which
+// package "provides" wins a tie.
+type DependencyResolver struct {
+	opts *options.Options
+
+	// providers maps a provided token - a package's own name, its origin,
+	// or one of its "provides" entries - to every package that offers it.
+	providers map[string][]*provider
+}
+
+// provider is a candidate package that can satisfy a dependency token.
+type provider struct {
+	name     string
+	version  string
+	priority int
+}
+
+// NewDependencyResolver indexes every provides/origin/soname exposed by the
+// packages in opts.Packages.
+func NewDependencyResolver(opts *options.Options) *DependencyResolver {
+	r := &DependencyResolver{
+		opts:      opts,
+		providers: map[string][]*provider{},
+	}
+
+	for _, pkg := range opts.Packages {
+		p := &provider{
+			name:     pkg.Name,
+			version:  pkg.Version,
+			priority: pkg.ProviderPriority,
+		}
+
+		// A package always self-provides its own name and origin.
+		r.add(pkg.Name, p)
+		if pkg.Origin != "" && pkg.Origin != pkg.Name {
+			r.add(pkg.Origin, p)
+		}
+		for _, provides := range pkg.Provides {
+			r.add(stripConstraint(provides), p)
+		}
+	}
+
+	return r
+}
+
+func (r *DependencyResolver) add(token string, p *provider) {
+	if token == "" {
+		return
+	}
+	r.providers[token] = append(r.providers[token], p)
+}
+
+// Resolve takes a raw dependency token as it appears in a package's "depend"
+// list (e.g. "so:libc.so.6", "cmd:sh", "pc:openssl>=3.0", or a plain package
+// name with an optional version constraint) and returns the purl of the
+// package apk-tools would pick to satisfy it.
+//
+// ok is false when no installed package provides the dependency; callers
+// should record the unsatisfied token rather than silently dropping it.
+func (r *DependencyResolver) Resolve(dep string) (resolvedPURL string, ok bool) {
+	token := stripConstraint(dep)
+	if token == "" {
+		return "", false
+	}
+
+	candidates := r.providers[token]
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// apk-tools picks the provider with the highest provider_priority,
+	// breaking ties on package name so the choice is deterministic.
+	winner := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.priority > winner.priority ||
+			(c.priority == winner.priority && c.name < winner.name) {
+			winner = c
+		}
+	}
+
+	mm := map[string]string{"arch": r.opts.ImageInfo.Arch.ToAPK()}
+	return purl.NewPackageURL(
+		"apk", r.opts.OS.ID, winner.name, winner.version,
+		purl.QualifiersFromMap(mm), "",
+	).String(), true
+}
+
+// stripConstraint removes the version/selector suffix apk attaches to
+// dependency and provides tokens, e.g. "openssl>=3.0" -> "openssl" or
+// "so:libc.so.6=1.2.3" -> "so:libc.so.6".
+func stripConstraint(token string) string {
+	if i := strings.IndexAny(token, " ~<>=/!"); i > -1 {
+		token = token[:i]
+	}
+	return token
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"testing"
+
+	"chainguard.dev/apko/pkg/sbom/options"
+)
+
+func testOptions(pkgs ...*options.Package) *options.Options {
+	opts := &options.Options{Packages: pkgs}
+	opts.OS.ID = "alpine"
+	return opts
+}
+
+func TestResolverSoname(t *testing.T) {
+	opts := testOptions(&options.Package{
+		Name:     "musl",
+		Version:  "1.2.4-r0",
+		Provides: []string{"so:libc.musl-x86_64.so.1"},
+	})
+
+	r := NewDependencyResolver(opts)
+
+	got, ok := r.Resolve("so:libc.musl-x86_64.so.1")
+	if !ok {
+		t.Fatalf("expected so:libc.musl-x86_64.so.1 to resolve")
+	}
+	if want := "pkg:apk/alpine/musl@1.2.4-r0"; got[:len(want)] != want {
+		t.Errorf("got %q, want prefix %q", got, want)
+	}
+}
+
+func TestResolverSelfProvides(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	r := NewDependencyResolver(opts)
+
+	if _, ok := r.Resolve("busybox>=1.36"); !ok {
+		t.Fatalf("expected a package to resolve its own name")
+	}
+}
+
+func TestResolverMultiProviderPriority(t *testing.T) {
+	opts := testOptions(
+		&options.Package{Name: "openssl3", Version: "3.1.4-r0", Provides: []string{"pc:openssl"}, ProviderPriority: 0},
+		&options.Package{Name: "libressl", Version: "3.8.2-r0", Provides: []string{"pc:openssl"}, ProviderPriority: 10},
+	)
+
+	r := NewDependencyResolver(opts)
+
+	got, ok := r.Resolve("pc:openssl")
+	if !ok {
+		t.Fatalf("expected pc:openssl to resolve")
+	}
+	want := "pkg:apk/alpine/libressl@3.8.2-r0"
+	if got[:len(want)] != want {
+		t.Errorf("got %q, want the higher-priority provider %q", got, want)
+	}
+}
+
+func TestResolverUnsatisfied(t *testing.T) {
+	opts := testOptions(&options.Package{Name: "busybox", Version: "1.36.1-r2"})
+
+	r := NewDependencyResolver(opts)
+
+	if _, ok := r.Resolve("so:libfoo.so.9"); ok {
+		t.Fatalf("expected an unprovided soname to be reported as unresolved")
+	}
+}